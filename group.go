@@ -0,0 +1,149 @@
+package dotweb
+
+import "strings"
+
+//RouteGroup 支持嵌套的路由分组，可挂载分组级中间件，注册时一次性编译好分组与路由级中间件链；
+//dotweb.Modules的OnBeginRequest/OnEndRequest不在该链中，仍由wrapRouterHandle在每次请求时遍历执行
+type RouteGroup struct {
+	server          *HttpServer
+	prefix          string
+	middlewares     []HttpHandle
+	exceptionHandle func(*HttpContext, interface{})
+}
+
+//Group 基于当前HttpServer创建一个顶层RouteGroup，prefix为路由前缀，如"/api/v1"
+func (server *HttpServer) Group(prefix string) *RouteGroup {
+	return &RouteGroup{
+		server: server,
+		prefix: normalizeGroupPrefix(prefix),
+	}
+}
+
+//Group 基于当前分组创建嵌套子分组，子分组继承父分组的前缀与中间件链
+func (group *RouteGroup) Group(prefix string) *RouteGroup {
+	child := &RouteGroup{
+		server:          group.server,
+		prefix:          group.prefix + normalizeGroupPrefix(prefix),
+		exceptionHandle: group.exceptionHandle,
+	}
+	child.middlewares = append(child.middlewares, group.middlewares...)
+	return child
+}
+
+//Use 为当前分组追加中间件，按注册顺序在真正的handle之前依次执行
+func (group *RouteGroup) Use(middleware ...HttpHandle) *RouteGroup {
+	group.middlewares = append(group.middlewares, middleware...)
+	return group
+}
+
+//SetExceptionHandler 覆盖当前分组（及其注册的路由）使用的异常处理函数
+func (group *RouteGroup) SetExceptionHandler(handle func(*HttpContext, interface{})) *RouteGroup {
+	group.exceptionHandle = handle
+	return group
+}
+
+func normalizeGroupPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
+
+//chain 把分组中间件与路由级中间件、最终handle编译成单个HttpHandle，在注册时执行一次，
+//避免每次请求都重新拼装调用链。
+//HttpHandle没有next()/abort返回值，中间件若想拒绝请求（如鉴权失败）又不想panic，
+//唯一的信号就是自己调用了ctx.Response.WriteHeader写出了响应：一旦检测到某个中间件
+//已经写过响应头，后续的中间件与真正的handle都不会再执行
+func (group *RouteGroup) chain(handle HttpHandle, routeMiddlewares ...HttpHandle) HttpHandle {
+	all := make([]HttpHandle, 0, len(group.middlewares)+len(routeMiddlewares)+1)
+	all = append(all, group.middlewares...)
+	all = append(all, routeMiddlewares...)
+	all = append(all, handle)
+
+	return func(ctx *HttpContext) {
+		defer func() {
+			if err := recover(); err != nil {
+				if group.exceptionHandle != nil {
+					group.exceptionHandle(ctx, err)
+					return
+				}
+				panic(err)
+			}
+		}()
+		for _, h := range all {
+			if ctx.Response.Status != 0 {
+				return
+			}
+			h(ctx)
+		}
+	}
+}
+
+func (group *RouteGroup) route(method string, path string, handle HttpHandle, middleware ...HttpHandle) {
+	compiled := group.chain(handle, middleware...)
+	fullPath := group.prefix + normalizeGroupPath(path)
+	switch method {
+	case "GET":
+		group.server.GET(fullPath, compiled)
+	case "HEAD":
+		group.server.HEAD(fullPath, compiled)
+	case "OPTIONS":
+		group.server.OPTIONS(fullPath, compiled)
+	case "POST":
+		group.server.POST(fullPath, compiled)
+	case "PUT":
+		group.server.PUT(fullPath, compiled)
+	case "PATCH":
+		group.server.PATCH(fullPath, compiled)
+	case "DELETE":
+		group.server.DELETE(fullPath, compiled)
+	}
+}
+
+func normalizeGroupPath(path string) string {
+	if path == "" || path == "/" {
+		return "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// GET is a shortcut for group.route("GET", path, handle, middleware...)
+func (group *RouteGroup) GET(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("GET", path, handle, middleware...)
+}
+
+// HEAD is a shortcut for group.route("HEAD", path, handle, middleware...)
+func (group *RouteGroup) HEAD(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("HEAD", path, handle, middleware...)
+}
+
+// OPTIONS is a shortcut for group.route("OPTIONS", path, handle, middleware...)
+func (group *RouteGroup) OPTIONS(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("OPTIONS", path, handle, middleware...)
+}
+
+// POST is a shortcut for group.route("POST", path, handle, middleware...)
+func (group *RouteGroup) POST(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("POST", path, handle, middleware...)
+}
+
+// PUT is a shortcut for group.route("PUT", path, handle, middleware...)
+func (group *RouteGroup) PUT(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("PUT", path, handle, middleware...)
+}
+
+// PATCH is a shortcut for group.route("PATCH", path, handle, middleware...)
+func (group *RouteGroup) PATCH(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("PATCH", path, handle, middleware...)
+}
+
+// DELETE is a shortcut for group.route("DELETE", path, handle, middleware...)
+func (group *RouteGroup) DELETE(path string, handle HttpHandle, middleware ...HttpHandle) {
+	group.route("DELETE", path, handle, middleware...)
+}
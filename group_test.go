@@ -0,0 +1,114 @@
+package dotweb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeGroupPrefix(t *testing.T) {
+	cases := []struct {
+		prefix string
+		want   string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"api", "/api"},
+		{"/api", "/api"},
+		{"/api/", "/api"},
+	}
+	for _, c := range cases {
+		if got := normalizeGroupPrefix(c.prefix); got != c.want {
+			t.Errorf("normalizeGroupPrefix(%q) = %q, want %q", c.prefix, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeGroupPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"login", "/login"},
+		{"/login", "/login"},
+	}
+	for _, c := range cases {
+		if got := normalizeGroupPath(c.path); got != c.want {
+			t.Errorf("normalizeGroupPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+//handlePointer 取HttpHandle闭包的函数指针，用于在不构造HttpContext的前提下
+//断言中间件链中的元素身份与顺序
+func handlePointer(h HttpHandle) uintptr {
+	return reflect.ValueOf(h).Pointer()
+}
+
+func TestGroupNestedInheritsMiddlewaresInOrder(t *testing.T) {
+	server := &HttpServer{}
+	mwA := func(ctx *HttpContext) {}
+	mwB := func(ctx *HttpContext) {}
+
+	parent := server.Group("/api")
+	parent.Use(mwA)
+
+	child := parent.Group("/v1")
+	child.Use(mwB)
+
+	if child.prefix != "/api/v1" {
+		t.Fatalf("child.prefix = %q, want /api/v1", child.prefix)
+	}
+	if len(child.middlewares) != 2 {
+		t.Fatalf("len(child.middlewares) = %d, want 2", len(child.middlewares))
+	}
+	if handlePointer(child.middlewares[0]) != handlePointer(mwA) {
+		t.Error("child.middlewares[0] should be the parent's middleware (inherited first)")
+	}
+	if handlePointer(child.middlewares[1]) != handlePointer(mwB) {
+		t.Error("child.middlewares[1] should be the child's own middleware (appended after)")
+	}
+
+	//父分组追加新中间件不应影响已经创建好的子分组，子分组在创建时已经拷贝了一份切片
+	parent.Use(func(ctx *HttpContext) {})
+	if len(child.middlewares) != 2 {
+		t.Fatal("appending to the parent group after a child was created must not affect the child's middlewares")
+	}
+}
+
+func TestGroupInheritsExceptionHandler(t *testing.T) {
+	server := &HttpServer{}
+	called := false
+	parent := server.Group("/api")
+	parent.SetExceptionHandler(func(ctx *HttpContext, err interface{}) { called = true })
+
+	child := parent.Group("/v1")
+	if child.exceptionHandle == nil {
+		t.Fatal("child should inherit the parent's exceptionHandle")
+	}
+	child.exceptionHandle(nil, nil)
+	if !called {
+		t.Fatal("child.exceptionHandle should be the same function the parent registered")
+	}
+}
+
+func TestChainStopsAfterResponseIsWritten(t *testing.T) {
+	var ran []string
+	group := (&HttpServer{}).Group("")
+	group.Use(func(ctx *HttpContext) {
+		ran = append(ran, "auth")
+		ctx.Response.Status = 401
+	})
+
+	compiled := group.chain(func(ctx *HttpContext) {
+		ran = append(ran, "handle")
+	})
+
+	ctx := &HttpContext{Response: &Response{}}
+	compiled(ctx)
+
+	if len(ran) != 1 || ran[0] != "auth" {
+		t.Fatalf("ran = %v, want [auth] — the real handle must not run once a middleware has written a response", ran)
+	}
+}
@@ -0,0 +1,168 @@
+package dotweb
+
+import (
+	"devfeel/dotweb/framework/log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//Metrics定义，HttpServer默认使用PrometheusMetrics实现，也可由使用方自行替换
+type Metrics interface {
+	//ObserveRequest 在一次普通HTTP请求结束时调用，pattern为httprouter匹配到的路由而非原始URL，以控制基数
+	ObserveRequest(method string, pattern string, status int, duration time.Duration, responseSize int64)
+	//BeginRequest/EndRequest 维护in-flight请求数
+	BeginRequest(method string, pattern string)
+	EndRequest(method string, pattern string)
+	//ObserveWebSocket 在一次websocket连接关闭时调用，记录连接时长与消息数
+	ObserveWebSocket(path string, lifetime time.Duration, messageCount int64)
+}
+
+//statusClass 将具体状态码归并为状态类（如200->2xx），控制label基数
+func statusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	case status >= 200:
+		return "2xx"
+	default:
+		return "1xx"
+	}
+}
+
+//PrometheusMetrics 是Metrics的默认实现，基于client_golang暴露标准的Prometheus指标
+type PrometheusMetrics struct {
+	requestTotal    *prometheus.CounterVec
+	requestInFlight *prometheus.GaugeVec
+	requestDuration *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+
+	wsConnectionDuration *prometheus.HistogramVec
+	wsMessageTotal       *prometheus.CounterVec
+
+	registry *prometheus.Registry
+}
+
+//durationBuckets 默认的请求耗时直方图分桶，覆盖5ms~10s
+var durationBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+//NewPrometheusMetrics 创建默认的Prometheus指标实现并注册到独立的prometheus.Registry
+func NewPrometheusMetrics() *PrometheusMetrics {
+	registry := prometheus.NewRegistry()
+
+	metrics := &PrometheusMetrics{
+		requestTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "dotweb",
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests processed, labeled by method, route and status class.",
+		}, []string{"method", "route", "status"}),
+
+		requestInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "dotweb",
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being processed.",
+		}, []string{"method", "route"}),
+
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dotweb",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request duration in seconds, labeled by method, route and status class.",
+			Buckets:   durationBuckets,
+		}, []string{"method", "route", "status"}),
+
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dotweb",
+			Name:      "http_response_size_bytes",
+			Help:      "HTTP response size in bytes, labeled by method, route and status class.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"method", "route", "status"}),
+
+		wsConnectionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dotweb",
+			Name:      "ws_connection_duration_seconds",
+			Help:      "WebSocket connection lifetime in seconds, labeled by route.",
+			Buckets:   durationBuckets,
+		}, []string{"route"}),
+
+		wsMessageTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "dotweb",
+			Name:      "ws_messages_total",
+			Help:      "Total number of WebSocket messages processed, labeled by route.",
+		}, []string{"route"}),
+
+		registry: registry,
+	}
+
+	registry.MustRegister(
+		metrics.requestTotal,
+		metrics.requestInFlight,
+		metrics.requestDuration,
+		metrics.responseSize,
+		metrics.wsConnectionDuration,
+		metrics.wsMessageTotal,
+	)
+
+	return metrics
+}
+
+func (m *PrometheusMetrics) BeginRequest(method string, pattern string) {
+	m.requestInFlight.WithLabelValues(method, pattern).Inc()
+}
+
+func (m *PrometheusMetrics) EndRequest(method string, pattern string) {
+	m.requestInFlight.WithLabelValues(method, pattern).Dec()
+}
+
+func (m *PrometheusMetrics) ObserveRequest(method string, pattern string, status int, duration time.Duration, responseSize int64) {
+	class := statusClass(status)
+	m.requestTotal.WithLabelValues(method, pattern, class).Inc()
+	m.requestDuration.WithLabelValues(method, pattern, class).Observe(duration.Seconds())
+	m.responseSize.WithLabelValues(method, pattern, class).Observe(float64(responseSize))
+}
+
+func (m *PrometheusMetrics) ObserveWebSocket(path string, lifetime time.Duration, messageCount int64) {
+	m.wsConnectionDuration.WithLabelValues(path).Observe(lifetime.Seconds())
+	m.wsMessageTotal.WithLabelValues(path).Add(float64(messageCount))
+}
+
+//Handler 返回可挂载到路由上的exposition端点
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+//RegisterMetricsHandler 将Metrics的exposition端点以GET方式挂载到path上，与其它路由共用同一个router；
+//若当前Metrics实现是通过EnableMetrics替换成自定义实现的（非*PrometheusMetrics），则没有现成的
+//exposition端点可挂载，记录一条警告而不是静默返回，避免调用方以为端点已经生效
+func (server *HttpServer) RegisterMetricsHandler(path string) {
+	prom, ok := server.getMetrics().(*PrometheusMetrics)
+	if !ok {
+		logger.Log("HttpServer:RegisterMetricsHandler skipped: current Metrics is not *PrometheusMetrics, no exposition handler to mount on "+path, LogTarget_HttpServer, LogLevel_Warn)
+		return
+	}
+	server.router.Handler("GET", path, prom.Handler())
+}
+
+//EnableMetrics 替换HttpServer使用的Metrics实现，不调用时默认使用NewPrometheusMetrics()
+func (server *HttpServer) EnableMetrics(metrics Metrics) {
+	server.metrics = metrics
+}
+
+//getMetrics 返回当前Metrics实现，未通过EnableMetrics显式设置时懒加载默认的PrometheusMetrics；
+//用sync.Once保证高并发下第一批请求只构造一次，不会出现多个Registry互相覆盖、丢失计数的情况
+func (server *HttpServer) getMetrics() Metrics {
+	server.metricsOnce.Do(func() {
+		if server.metrics == nil {
+			server.metrics = NewPrometheusMetrics()
+		}
+	})
+	return server.metrics
+}
+
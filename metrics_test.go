@@ -0,0 +1,29 @@
+package dotweb
+
+import "testing"
+
+func TestStatusClass(t *testing.T) {
+	cases := map[int]string{
+		200: "2xx",
+		201: "2xx",
+		301: "3xx",
+		404: "4xx",
+		500: "5xx",
+		100: "1xx",
+	}
+	for status, want := range cases {
+		if got := statusClass(status); got != want {
+			t.Errorf("statusClass(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestGetMetricsReturnsSameInstance(t *testing.T) {
+	server := NewHttpServer()
+
+	first := server.getMetrics()
+	second := server.getMetrics()
+	if first != second {
+		t.Fatal("getMetrics should lazily initialize the default Metrics exactly once")
+	}
+}
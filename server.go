@@ -27,9 +27,17 @@ type (
 
 	//HttpServer定义
 	HttpServer struct {
-		router *httprouter.Router
-		dotweb *Dotweb
-		pool   *pool
+		router         *httprouter.Router
+		dotweb         *Dotweb
+		pool           *pool
+		serverConfig   *ServerConfig
+		scriptCache    *scriptCache
+		sessionManager *sessionManager
+		compression    *compressionConfig
+		metrics        Metrics
+		metricsOnce    sync.Once
+		//ApiHotLoadInterval 控制RegisterScriptDir后台重新扫描目录的间隔，默认3000ms
+		ApiHotLoadInterval time.Duration
 	}
 
 	//pool定义
@@ -84,42 +92,42 @@ func (server *HttpServer) setDotweb(dotweb *Dotweb) {
 
 // GET is a shortcut for router.Handle("GET", path, handle)
 func (server *HttpServer) GET(path string, handle HttpHandle) {
-	server.router.Handle("GET", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("GET", path, server.wrapRouterHandle("GET", path, handle, false))
 }
 
 // HEAD is a shortcut for router.Handle("HEAD", path, handle)
 func (server *HttpServer) HEAD(path string, handle HttpHandle) {
-	server.router.Handle("HEAD", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("HEAD", path, server.wrapRouterHandle("HEAD", path, handle, false))
 }
 
 // OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle)
 func (server *HttpServer) OPTIONS(path string, handle HttpHandle) {
-	server.router.Handle("OPTIONS", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("OPTIONS", path, server.wrapRouterHandle("OPTIONS", path, handle, false))
 }
 
 // POST is a shortcut for router.Handle("POST", path, handle)
 func (server *HttpServer) POST(path string, handle HttpHandle) {
-	server.router.Handle("POST", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("POST", path, server.wrapRouterHandle("POST", path, handle, false))
 }
 
 // PUT is a shortcut for router.Handle("PUT", path, handle)
 func (server *HttpServer) PUT(path string, handle HttpHandle) {
-	server.router.Handle("PUT", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("PUT", path, server.wrapRouterHandle("PUT", path, handle, false))
 }
 
 // PATCH is a shortcut for router.Handle("PATCH", path, handle)
 func (server *HttpServer) PATCH(path string, handle HttpHandle) {
-	server.router.Handle("PATCH", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("PATCH", path, server.wrapRouterHandle("PATCH", path, handle, false))
 }
 
 // DELETE is a shortcut for router.Handle("DELETE", path, handle)
 func (server *HttpServer) DELETE(path string, handle HttpHandle) {
-	server.router.Handle("DELETE", path, server.wrapRouterHandle(handle, false))
+	server.router.Handle("DELETE", path, server.wrapRouterHandle("DELETE", path, handle, false))
 }
 
 // DELETE is a shortcut for router.Handle("DELETE", path, handle)
 func (server *HttpServer) HiJack(path string, handle HttpHandle) {
-	server.router.Handle("GET", path, server.wrapRouterHandle(handle, true))
+	server.router.Handle("GET", path, server.wrapRouterHandle("GET", path, handle, true))
 }
 
 // ServerFile is a shortcut for router.ServeFiles(path, filepath)
@@ -130,7 +138,7 @@ func (server *HttpServer) ServerFile(urlpath string, filepath string) {
 
 // WebSocket is a shortcut for websocket.Handler
 func (server *HttpServer) WebSocket(path string, handle HttpHandle) {
-	http.Handle(path, websocket.Handler(server.wrapWebSocketHandle(handle)))
+	http.Handle(path, websocket.Handler(server.wrapWebSocketHandle(path, handle)))
 }
 
 type LogJson struct {
@@ -140,14 +148,23 @@ type LogJson struct {
 }
 
 //wrap HttpHandle to httprouter.Handle
-func (server *HttpServer) wrapRouterHandle(handle HttpHandle, isHijack bool) httprouter.Handle {
+func (server *HttpServer) wrapRouterHandle(method string, pattern string, handle HttpHandle, isHijack bool) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		//按Accept-Encoding协商结果包装真正的http.ResponseWriter：必须在res.Reset(w)之前完成，
+		//这样压缩与否的决定（以及Content-Encoding/Vary等响应头的设置）发生在Response.WriteHeader
+		//把状态行提交给底层连接之前，而不是事后再去改一个已经发出去的响应头
+		wrappedWriter, compressionCleanup := server.wrapCompression(w, r, isHijack)
+
 		//get from pool
 		res := server.pool.response.Get().(*Response)
-		res.Reset(w)
+		res.Reset(wrappedWriter)
 		httpCtx := server.pool.context.Get().(*HttpContext)
 		httpCtx.Reset(res, r, params)
 
+		//记录in-flight请求数，keyed by httprouter的匹配模式而非原始URL，避免基数爆炸
+		metrics := server.getMetrics()
+		metrics.BeginRequest(method, pattern)
+
 		//hijack处理
 		if isHijack {
 			_, hijack_err := httpCtx.Hijack()
@@ -159,8 +176,17 @@ func (server *HttpServer) wrapRouterHandle(handle HttpHandle, isHijack bool) htt
 			}
 		}
 
+		//处理session：读取/创建session cookie，数据延迟加载，响应结束时仅在被修改过才写回store
+		if server.sessionManager != nil {
+			server.sessionManager.beginSession(httpCtx)
+		}
+
 		startTime := time.Now()
 		defer func() {
+			if server.sessionManager != nil {
+				server.sessionManager.endSession(httpCtx)
+			}
+
 			var errmsg string
 			if err := recover(); err != nil {
 				errmsg = exception.CatchError("httpserver::RouterHandle", LogTarget_HttpServer, err)
@@ -183,14 +209,21 @@ func (server *HttpServer) wrapRouterHandle(handle HttpHandle, isHijack bool) htt
 				}
 				logString := jsonutil.GetJsonString(logJson)
 				logger.Log(logString, LogTarget_HttpServer, LogLevel_Error)
-
-				//增加错误计数
-				GlobalState.AddErrorCount(1)
 			}
-			timetaken := int64(time.Now().Sub(startTime) / time.Millisecond)
+			elapsed := time.Now().Sub(startTime)
+			timetaken := int64(elapsed / time.Millisecond)
 			//HttpServer Logging
 			logger.Log(httpCtx.Url()+" "+logString(httpCtx, timetaken), LogTarget_HttpRequest, LogLevel_Debug)
 
+			//记录请求总量、耗时直方图、响应体大小直方图，并减少in-flight计数
+			metrics.ObserveRequest(method, pattern, httpCtx.Response.Status, elapsed, httpCtx.Response.Size)
+			metrics.EndRequest(method, pattern)
+
+			//必须在错误处理/日志写完之后再关闭压缩流：一旦panic恢复路径也要向res.writer写入内容
+			//（例如上面的io.WriteString(httpCtx.Response.writer, errmsg)或自定义ExceptionHandler），
+			//提前Close会把gzip.Writer归还sync.Pool，被其他并发请求Reset()复用，导致写坏别的响应
+			compressionCleanup()
+
 			// Return to pool
 			server.pool.response.Put(res)
 			server.pool.context.Put(httpCtx)
@@ -212,14 +245,11 @@ func (server *HttpServer) wrapRouterHandle(handle HttpHandle, isHijack bool) htt
 				module.OnEndRequest(httpCtx)
 			}
 		}
-
-		//增加状态计数
-		GlobalState.AddRequestCount(1)
 	}
 }
 
 //wrap HttpHandle to websocket.Handle
-func (server *HttpServer) wrapWebSocketHandle(handle HttpHandle) websocket.Handler {
+func (server *HttpServer) wrapWebSocketHandle(path string, handle HttpHandle) websocket.Handler {
 	return func(ws *websocket.Conn) {
 		//get from pool
 		httpCtx := server.pool.context.Get().(*HttpContext)
@@ -229,6 +259,8 @@ func (server *HttpServer) wrapWebSocketHandle(handle HttpHandle) websocket.Handl
 		}
 		httpCtx.IsWebSocket = true
 
+		metrics := server.getMetrics()
+
 		startTime := time.Now()
 		defer func() {
 			var errmsg string
@@ -244,22 +276,20 @@ func (server *HttpServer) wrapWebSocketHandle(handle HttpHandle) websocket.Handl
 				}
 				logString := jsonutil.GetJsonString(logJson)
 				logger.Log(logString, LogTarget_HttpServer, LogLevel_Error)
-
-				//增加错误计数
-				GlobalState.AddErrorCount(1)
 			}
-			timetaken := int64(time.Now().Sub(startTime) / time.Millisecond)
+			elapsed := time.Now().Sub(startTime)
+			timetaken := int64(elapsed / time.Millisecond)
 			//HttpServer Logging
 			logger.Log(httpCtx.Url()+" "+logString(httpCtx, timetaken), LogTarget_HttpRequest, LogLevel_Debug)
 
+			//websocket连接生命周期与消息数单独计入指标，不与普通HTTP请求的耗时直方图混算
+			metrics.ObserveWebSocket(path, elapsed, httpCtx.WebSocket.MessageCount)
+
 			// Return to pool
 			server.pool.context.Put(httpCtx)
 		}()
 
 		handle(httpCtx)
-
-		//增加状态计数
-		GlobalState.AddRequestCount(1)
 	}
 }
 
@@ -295,8 +325,9 @@ func logString(ctx *HttpContext, timetaken int64) string {
 }
 
 //check request is the websocket request
+//注意：h2c也会带上Connection: Upgrade头，这里需要通过Upgrade头的值区分，避免H2C升级请求被错误地当成websocket处理
 func checkIsWebSocketRequest(req *http.Request) bool {
-	if req.Header.Get("Connection") == "Upgrade" {
+	if req.Header.Get("Connection") == "Upgrade" && strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
 		return true
 	}
 	return false
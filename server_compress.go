@@ -0,0 +1,189 @@
+package dotweb
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"devfeel/dotweb/framework/log"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	HeaderAcceptEncoding  = "Accept-Encoding"
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderVary            = "Vary"
+)
+
+//compressionConfig 由HttpServer.EnableCompression设置，level为压缩级别，minBytes为触发压缩的首次写入最小长度；
+//gzipPool缓存按level构造好的*gzip.Writer，避免每个请求都重新分配
+type compressionConfig struct {
+	level    int
+	minBytes int
+	gzipPool *sync.Pool
+}
+
+var compressibleContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+//EnableCompression 为HttpServer开启gzip/deflate压缩，level为compress/gzip定义的压缩级别
+//（如gzip.DefaultCompression），minBytes为触发压缩的最小写入大小：是否压缩只按首次Write的数据长度判断，
+//首次写入小于该值的响应整体不压缩，即使后续写入累计超过minBytes
+func (server *HttpServer) EnableCompression(level int, minBytes int) {
+	server.compression = &compressionConfig{
+		level:    level,
+		minBytes: minBytes,
+		//gzip.Writer必须在首次构造时就带上目标level——zero-value的gzip.Writer在Reset()时会
+		//沿用其内部尚未初始化的z.level（0，即NoCompression），因此池里缓存的必须是已经用
+		//NewWriterLevel(level)构造过的实例，而不是new(gzip.Writer)
+		gzipPool: &sync.Pool{
+			New: func() interface{} {
+				gz, err := gzip.NewWriterLevel(ioutil.Discard, level)
+				if err != nil {
+					logger.Log("HttpServer:EnableCompression invalid gzip level, falling back to default:"+err.Error(), LogTarget_HttpServer, LogLevel_Warn)
+					gz, _ = gzip.NewWriterLevel(ioutil.Discard, gzip.DefaultCompression)
+				}
+				return gz
+			},
+		},
+	}
+}
+
+//isCompressibleContentType 判断响应Content-Type是否属于可压缩的文本类内容，未显式设置时默认当作可压缩处理
+func isCompressibleContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	for _, prefix := range compressibleContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//negotiateEncoding 根据Accept-Encoding协商出gzip、deflate或不压缩("")，优先gzip
+func negotiateEncoding(acceptEncoding string) string {
+	if strings.Contains(acceptEncoding, "gzip") {
+		return "gzip"
+	}
+	if strings.Contains(acceptEncoding, "deflate") {
+		return "deflate"
+	}
+	return ""
+}
+
+//wrapCompression 在真正的http.ResponseWriter外包一层compressResponseWriter（而不是事后替换
+//Response.writer），这样压缩与否的决定发生在Response.WriteHeader真正把状态行/响应头提交给
+//底层连接之前；返回的cleanup函数需在请求处理完成后（defer中）调用，以便flush、关闭压缩流
+//并归还gzip.Writer。Hijack场景直接跳过包装
+func (server *HttpServer) wrapCompression(w http.ResponseWriter, r *http.Request, isHijack bool) (http.ResponseWriter, func()) {
+	noop := func() {}
+	if server.compression == nil || isHijack {
+		return w, noop
+	}
+
+	encoding := negotiateEncoding(r.Header.Get(HeaderAcceptEncoding))
+	if encoding == "" {
+		return w, noop
+	}
+
+	crw := &compressResponseWriter{
+		ResponseWriter: w,
+		config:         server.compression,
+		encoding:       encoding,
+	}
+	return crw, crw.Close
+}
+
+//compressResponseWriter 包装底层http.ResponseWriter，将WriteHeader推迟到第一次真正写入响应体时才提交，
+//从而能在状态行/响应头发出之前根据Content-Type与本次写入大小决定是否启用压缩并设置好Content-Encoding；
+//一旦决定，后续所有写入都经由压缩流写出，直到Close统一flush/归还资源
+type compressResponseWriter struct {
+	http.ResponseWriter
+	config   *compressionConfig
+	encoding string
+
+	statusCode    int
+	headerWritten bool
+
+	decided    bool
+	compressed bool
+	gz         *gzip.Writer
+	fl         *flate.Writer
+}
+
+//WriteHeader 只记录状态码，真正提交要推迟到首次Write（或Close）时，此时才能确定是否压缩
+func (crw *compressResponseWriter) WriteHeader(statusCode int) {
+	if crw.headerWritten {
+		return
+	}
+	crw.statusCode = statusCode
+}
+
+func (crw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !crw.decided {
+		crw.decided = true
+		if len(p) >= crw.config.minBytes && isCompressibleContentType(crw.Header().Get(HeaderContentType)) {
+			crw.compressed = true
+			crw.Header().Set(HeaderContentEncoding, crw.encoding)
+			crw.Header().Set(HeaderVary, HeaderAcceptEncoding)
+			crw.Header().Del(HeaderContentLength)
+			if crw.encoding == "gzip" {
+				crw.gz = crw.config.gzipPool.Get().(*gzip.Writer)
+				crw.gz.Reset(crw.ResponseWriter)
+			} else {
+				fl, err := flate.NewWriter(crw.ResponseWriter, crw.config.level)
+				if err != nil {
+					logger.Log("HttpServer:compressResponseWriter invalid flate level, falling back to no compression:"+err.Error(), LogTarget_HttpServer, LogLevel_Warn)
+					crw.compressed = false
+					crw.Header().Del(HeaderContentEncoding)
+				} else {
+					crw.fl = fl
+				}
+			}
+		}
+		crw.commitHeader()
+	}
+
+	if !crw.compressed {
+		return crw.ResponseWriter.Write(p)
+	}
+	if crw.gz != nil {
+		return crw.gz.Write(p)
+	}
+	return crw.fl.Write(p)
+}
+
+//commitHeader 把推迟的WriteHeader真正提交给底层ResponseWriter；未显式调用过WriteHeader时按
+//http.ResponseWriter的约定视为200
+func (crw *compressResponseWriter) commitHeader() {
+	if crw.headerWritten {
+		return
+	}
+	crw.headerWritten = true
+	status := crw.statusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	crw.ResponseWriter.WriteHeader(status)
+}
+
+//Close 关闭压缩流并归还可复用的gzip.Writer；若请求处理过程中从未写入过响应体（例如无body的204），
+//确保被推迟的状态行仍然会被提交
+func (crw *compressResponseWriter) Close() {
+	crw.commitHeader()
+	if crw.gz != nil {
+		crw.gz.Close()
+		crw.config.gzipPool.Put(crw.gz)
+	}
+	if crw.fl != nil {
+		crw.fl.Close()
+	}
+}
@@ -0,0 +1,183 @@
+package dotweb
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"gzip, deflate", "gzip"},
+		{"deflate", "deflate"},
+		{"br", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := negotiateEncoding(c.acceptEncoding); got != c.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", c.acceptEncoding, got, c.want)
+		}
+	}
+}
+
+func TestIsCompressibleContentType(t *testing.T) {
+	cases := []struct {
+		contentType string
+		want        bool
+	}{
+		{"", true},
+		{"text/html; charset=utf-8", true},
+		{"application/json", true},
+		{"application/javascript", true},
+		{"image/png", false},
+	}
+	for _, c := range cases {
+		if got := isCompressibleContentType(c.contentType); got != c.want {
+			t.Errorf("isCompressibleContentType(%q) = %v, want %v", c.contentType, got, c.want)
+		}
+	}
+}
+
+func newTestCompressionConfig(level int, minBytes int) *compressionConfig {
+	return &compressionConfig{
+		level:    level,
+		minBytes: minBytes,
+		gzipPool: &sync.Pool{
+			New: func() interface{} {
+				gz, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+				return gz
+			},
+		},
+	}
+}
+
+func TestCompressResponseWriterSkipsBelowMinBytes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	crw := &compressResponseWriter{
+		ResponseWriter: rec,
+		config:         newTestCompressionConfig(gzip.DefaultCompression, 1024),
+		encoding:       "gzip",
+	}
+
+	crw.WriteHeader(http.StatusOK)
+	if _, err := crw.Write([]byte("short")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crw.Close()
+
+	if crw.compressed {
+		t.Fatal("expected no compression for a write below minBytes")
+	}
+	if rec.Header().Get(HeaderContentEncoding) != "" {
+		t.Fatal("Content-Encoding should not be set when compression was skipped")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCompressResponseWriterEnablesAboveMinBytes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	crw := &compressResponseWriter{
+		ResponseWriter: rec,
+		config:         newTestCompressionConfig(gzip.DefaultCompression, 10),
+		encoding:       "gzip",
+	}
+	crw.Header().Set(HeaderContentLength, "2048")
+
+	if _, err := crw.Write(make([]byte, 2048)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crw.Close()
+
+	if !crw.compressed {
+		t.Fatal("expected compression to be enabled for a write above minBytes")
+	}
+	if rec.Header().Get(HeaderContentEncoding) != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", rec.Header().Get(HeaderContentEncoding))
+	}
+	if rec.Header().Get(HeaderContentLength) != "" {
+		t.Fatal("Content-Length should be stripped once compression is enabled")
+	}
+}
+
+//TestCompressResponseWriterDefersHeaderUntilDecided 验证WriteHeader不会在决定是否压缩之前
+//就把状态行提交给底层ResponseWriter，否则Content-Encoding会在响应头已经发出之后才被设置
+func TestCompressResponseWriterDefersHeaderUntilDecided(t *testing.T) {
+	rec := httptest.NewRecorder()
+	crw := &compressResponseWriter{
+		ResponseWriter: rec,
+		config:         newTestCompressionConfig(gzip.DefaultCompression, 1),
+		encoding:       "gzip",
+	}
+	crw.Header().Set(HeaderContentType, "application/json")
+	crw.WriteHeader(http.StatusCreated)
+
+	if crw.headerWritten {
+		t.Fatal("WriteHeader must not commit to the underlying ResponseWriter before a decision is made")
+	}
+
+	if _, err := crw.Write([]byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crw.Close()
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", rec.Code)
+	}
+	//rec.Result()返回的是WriteHeader提交那一刻的响应头快照，而rec.Header()是之后仍可修改的map
+	if rec.Result().Header.Get(HeaderContentEncoding) != "gzip" {
+		t.Fatal("Content-Encoding should have been set before the deferred WriteHeader was committed")
+	}
+}
+
+func TestGzipPoolSeedsConfiguredLevel(t *testing.T) {
+	server := &HttpServer{}
+	server.EnableCompression(gzip.BestCompression, 0)
+	config := server.compression
+	gz := config.gzipPool.Get().(*gzip.Writer)
+	defer config.gzipPool.Put(gz)
+
+	var buf bytesBuffer
+	gz.Reset(&buf)
+	if _, err := gz.Write([]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gz.Close()
+
+	bestCompressed := buf.Len()
+
+	server2 := &HttpServer{}
+	server2.EnableCompression(gzip.NoCompression, 0)
+	config2 := server2.compression
+	gz2 := config2.gzipPool.Get().(*gzip.Writer)
+	var buf2 bytesBuffer
+	gz2.Reset(&buf2)
+	if _, err := gz2.Write([]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gz2.Close()
+
+	if buf2.Len() <= bestCompressed {
+		t.Fatalf("NoCompression output (%d bytes) should be larger than BestCompression output (%d bytes); pool is not honoring the configured level", buf2.Len(), bestCompressed)
+	}
+}
+
+type bytesBuffer struct {
+	data []byte
+}
+
+func (b *bytesBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *bytesBuffer) Len() int {
+	return len(b.data)
+}
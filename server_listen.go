@@ -0,0 +1,127 @@
+package dotweb
+
+import (
+	"crypto/tls"
+	"devfeel/dotweb/framework/log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+//ServerMode HttpServer的启动模式
+type ServerMode string
+
+const (
+	//ServerMode_HTTP1 普通HTTP/1.1模式
+	ServerMode_HTTP1 ServerMode = "h1"
+	//ServerMode_HTTP2 基于TLS的HTTP/2模式
+	ServerMode_HTTP2 ServerMode = "h2"
+	//ServerMode_H2C 明文HTTP/2模式，用于反向代理或gRPC风格客户端
+	ServerMode_H2C ServerMode = "h2c"
+	//ServerMode_HTTPS 基于TLS的HTTPS模式（含ACME自动签发）
+	ServerMode_HTTPS ServerMode = "https"
+)
+
+//ServerConfig 用于控制HttpServer的启动方式
+type ServerConfig struct {
+	//Mode 指定启动模式，支持h1、h2、h2c、https，默认为h1
+	Mode ServerMode
+
+	//TLSCertFile、TLSKeyFile 用于https/h2模式下的静态证书
+	TLSCertFile string
+	TLSKeyFile  string
+
+	//ACMEDomains 非空时启用autocert，自动向Let's Encrypt申请并续期证书
+	ACMEDomains []string
+	//ACMECacheDir 证书缓存目录，默认acme-cache
+	ACMECacheDir string
+
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+}
+
+//NewServerConfig 返回带有dotweb默认超时参数的ServerConfig
+func NewServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Mode:           ServerMode_HTTP1,
+		ReadTimeout:    15 * time.Second,
+		WriteTimeout:   15 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+}
+
+//SetServerConfig 设置当前HttpServer使用的ServerConfig
+func (server *HttpServer) SetServerConfig(config *ServerConfig) {
+	server.serverConfig = config
+}
+
+func (server *HttpServer) getServerConfig() *ServerConfig {
+	if server.serverConfig == nil {
+		server.serverConfig = NewServerConfig()
+	}
+	return server.serverConfig
+}
+
+//buildStdServer 依据ServerConfig构造底层*http.Server，h2c模式下附加h2c.Handler以支持明文HTTP/2升级
+func (server *HttpServer) buildStdServer(addr string, config *ServerConfig) *http.Server {
+	stdServer := &http.Server{
+		Addr:           addr,
+		Handler:        server,
+		ReadTimeout:    config.ReadTimeout,
+		WriteTimeout:   config.WriteTimeout,
+		IdleTimeout:    config.IdleTimeout,
+		MaxHeaderBytes: config.MaxHeaderBytes,
+	}
+	if config.Mode == ServerMode_H2C {
+		stdServer.Handler = h2c.NewHandler(server, &http2.Server{})
+	}
+	return stdServer
+}
+
+//ListenAndServe 根据ServerConfig.Mode启动HttpServer，支持h1、h2c两种明文模式
+func (server *HttpServer) ListenAndServe(addr string) error {
+	config := server.getServerConfig()
+	if config.Mode == ServerMode_HTTPS || config.Mode == ServerMode_HTTP2 {
+		return server.ListenAndServeTLS(addr)
+	}
+	logger.Log("HttpServer:ListenAndServe ["+string(config.Mode)+"] "+addr, LogTarget_HttpServer, LogLevel_Debug)
+	return server.buildStdServer(addr, config).ListenAndServe()
+}
+
+//ListenAndServeTLS 以TLS方式启动HttpServer，当配置了ACMEDomains时通过autocert自动签发/续期证书，
+//否则使用ServerConfig.TLSCertFile/TLSKeyFile指定的静态证书
+func (server *HttpServer) ListenAndServeTLS(addr string) error {
+	config := server.getServerConfig()
+	stdServer := server.buildStdServer(addr, config)
+
+	if len(config.ACMEDomains) > 0 {
+		cacheDir := config.ACMECacheDir
+		if cacheDir == "" {
+			cacheDir = "acme-cache"
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.ACMEDomains...),
+			Cache:      autocert.DirCache(cacheDir),
+		}
+		stdServer.TLSConfig = &tls.Config{GetCertificate: manager.GetCertificate}
+		go func() {
+			//ACME HTTP-01 challenge要求:80必须能正常响应，绑定失败意味着证书续期会一直静默失败，
+			//因此这里不能像之前那样直接丢弃错误，必须记录下来
+			if err := http.ListenAndServe(":80", manager.HTTPHandler(nil)); err != nil {
+				logger.Log("HttpServer:ListenAndServeTLS acme challenge listener on :80 failed:"+err.Error(), LogTarget_HttpServer, LogLevel_Error)
+			}
+		}()
+		logger.Log("HttpServer:ListenAndServeTLS [acme] "+addr, LogTarget_HttpServer, LogLevel_Debug)
+		return stdServer.ListenAndServeTLS("", "")
+	}
+
+	logger.Log("HttpServer:ListenAndServeTLS ["+string(config.Mode)+"] "+addr, LogTarget_HttpServer, LogLevel_Debug)
+	return stdServer.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+}
@@ -0,0 +1,236 @@
+package dotweb
+
+import (
+	"devfeel/dotweb/framework/exception"
+	"devfeel/dotweb/framework/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+type (
+	//APICache 缓存单个脚本文件的编译结果，避免每次请求都重新编译
+	APICache struct {
+		Filename string
+		Mtime    time.Time
+		Code     *goja.Program
+	}
+
+	//scriptRoute 记录一个脚本文件对应的method+path，便于重新扫描时比对
+	scriptRoute struct {
+		method string
+		path   string
+	}
+)
+
+//RegisterScriptDir 扫描dir目录下的.js文件，并以urlPrefix为前缀逐一注册为路由
+//例如 api/user/login.js 会被注册为 POST urlPrefix+/user/login
+//脚本在goja虚拟机中执行，绑定与HttpContext对应的ctx对象，脚本的返回值不被使用，执行异常通过ExceptionHandler对外暴露
+//后台goroutine每隔ApiHotLoadInterval重新扫描目录，完成增量编译、更新与淘汰，无需重启进程
+func (server *HttpServer) RegisterScriptDir(urlPrefix string, dir string) error {
+	if server.scriptCache == nil {
+		server.scriptCache = &scriptCache{
+			caches: make(map[string]*APICache),
+			routes: make(map[string]*scriptRoute),
+		}
+	}
+
+	if err := server.scanScriptDir(urlPrefix, dir); err != nil {
+		return err
+	}
+
+	interval := server.ApiHotLoadInterval
+	if interval <= 0 {
+		interval = 3000 * time.Millisecond
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			server.safeScanScriptDir(urlPrefix, dir)
+		}
+	}()
+
+	return nil
+}
+
+//safeScanScriptDir 是scanScriptDir在后台goroutine中的包装，单独recover自身的panic，
+//避免一次扫描中的意外错误（如某个文件扫描时发生panic）拖垮整个进程，导致"热加载无需重启"的目标落空
+func (server *HttpServer) safeScanScriptDir(urlPrefix string, dir string) {
+	defer func() {
+		if err := recover(); err != nil {
+			errmsg := exception.CatchError("httpserver::ScriptDirScan", LogTarget_HttpServer, err)
+			logger.Log("HttpServer:RegisterScriptDir scan panic:"+errmsg, LogTarget_HttpServer, LogLevel_Error)
+		}
+	}()
+	if err := server.scanScriptDir(urlPrefix, dir); err != nil {
+		logger.Log("HttpServer:RegisterScriptDir scan error:"+err.Error(), LogTarget_HttpServer, LogLevel_Error)
+	}
+}
+
+//scriptKey 把urlPrefix（即调用RegisterScriptDir时挂载的前缀）与目录内相对路径组合成
+//scriptCache的map key，确保多次RegisterScriptDir调用（不同目录但恰好有同名相对路径，
+//例如都存在user/login.js）不会互相覆盖彼此的缓存或路由
+func scriptKey(urlPrefix string, rel string) string {
+	return urlPrefix + "\x00" + rel
+}
+
+//scanScriptDir 扫描一次目录，完成新增/变更文件的编译、已删除文件的淘汰
+func (server *HttpServer) scanScriptDir(urlPrefix string, dir string) error {
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".js") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := scriptKey(urlPrefix, rel)
+		seen[key] = true
+
+		server.scriptCache.mutex.RLock()
+		cache, exists := server.scriptCache.caches[key]
+		server.scriptCache.mutex.RUnlock()
+		if exists && cache.Mtime.Equal(info.ModTime()) {
+			return nil
+		}
+
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		program, err := goja.Compile(path, string(source), false)
+		if err != nil {
+			logger.Log("HttpServer:scanScriptDir compile error["+path+"]:"+err.Error(), LogTarget_HttpServer, LogLevel_Error)
+			return nil
+		}
+
+		newCache := &APICache{
+			Filename: rel,
+			Mtime:    info.ModTime(),
+			Code:     program,
+		}
+
+		server.scriptCache.mutex.Lock()
+		server.scriptCache.caches[key] = newCache
+		route, alreadyRegistered := server.scriptCache.routes[key]
+		if !alreadyRegistered {
+			method, routePath := scriptRouteFor(urlPrefix, rel)
+			route = &scriptRoute{method: method, path: routePath}
+			server.scriptCache.routes[key] = route
+		}
+		server.scriptCache.mutex.Unlock()
+
+		//httprouter不支持注销路由，因此每个method+path只能注册一次：即使文件后来被删除又重新创建，
+		//也只在首次出现时调用registerScriptRoute，之后始终复用同一条已注册的路由
+		if !alreadyRegistered {
+			server.registerScriptRoute(route.method, route.path, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	//淘汰已被删除的脚本文件：仅清理编译缓存（使请求回落到404），已注册的路由永久保留在routes中，
+	//避免文件被删除后重新创建时对同一method+path重复调用httprouter.Handle而panic。
+	//只清理属于当前urlPrefix前缀的key，不影响其它RegisterScriptDir调用的缓存
+	prefix := urlPrefix + "\x00"
+	server.scriptCache.mutex.Lock()
+	for key := range server.scriptCache.caches {
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			delete(server.scriptCache.caches, key)
+		}
+	}
+	server.scriptCache.mutex.Unlock()
+
+	return nil
+}
+
+//scriptRouteFor 将脚本相对路径转换为method+url，例如 user/login.js -> POST /user/login
+func scriptRouteFor(urlPrefix string, rel string) (method string, path string) {
+	rel = strings.TrimSuffix(rel, ".js")
+	rel = filepath.ToSlash(rel)
+	parts := strings.Split(rel, "/")
+	action := strings.ToLower(parts[len(parts)-1])
+
+	switch action {
+	case "login", "create", "add", "save":
+		method = "POST"
+	case "delete", "remove":
+		method = "DELETE"
+	case "update", "modify":
+		method = "PUT"
+	default:
+		method = "GET"
+	}
+
+	path = urlPrefix
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	path += rel
+	return method, path
+}
+
+//registerScriptRoute 将脚本注册为真正的路由，每次请求都从缓存中取出已编译的Program执行
+//cacheKey由scriptKey(urlPrefix, rel)构造，而非单纯的rel，避免跨前缀的同名脚本互相覆盖
+func (server *HttpServer) registerScriptRoute(method string, path string, cacheKey string) {
+	handle := func(ctx *HttpContext) {
+		server.scriptCache.mutex.RLock()
+		cache, exists := server.scriptCache.caches[cacheKey]
+		server.scriptCache.mutex.RUnlock()
+		if !exists {
+			ctx.Response.WriteHeader(404)
+			return
+		}
+
+		vm := goja.New()
+		vm.Set("ctx", ctx)
+
+		defer func() {
+			if err := recover(); err != nil {
+				errmsg := exception.CatchError("httpserver::ScriptHandle["+cacheKey+"]", LogTarget_HttpServer, err)
+				if server.dotweb.ExceptionHandler != nil {
+					server.dotweb.ExceptionHandler(ctx, err)
+				} else {
+					ctx.Response.WriteHeader(500)
+					ctx.WriteString(errmsg)
+				}
+			}
+		}()
+
+		if _, err := vm.RunProgram(cache.Code); err != nil {
+			panic(err)
+		}
+	}
+
+	switch method {
+	case "POST":
+		server.POST(path, handle)
+	case "DELETE":
+		server.DELETE(path, handle)
+	case "PUT":
+		server.PUT(path, handle)
+	default:
+		server.GET(path, handle)
+	}
+}
+
+//scriptCache 维护脚本文件名到编译结果、路由的映射，由RWMutex保护以支持热加载goroutine与请求处理并发访问
+type scriptCache struct {
+	mutex  sync.RWMutex
+	caches map[string]*APICache
+	routes map[string]*scriptRoute
+}
@@ -0,0 +1,39 @@
+package dotweb
+
+import "testing"
+
+func TestScriptRouteFor(t *testing.T) {
+	cases := []struct {
+		urlPrefix  string
+		rel        string
+		wantMethod string
+		wantPath   string
+	}{
+		{"api", "user/login.js", "POST", "api/user/login"},
+		{"api", "user/create.js", "POST", "api/user/create"},
+		{"api", "user/delete.js", "DELETE", "api/user/delete"},
+		{"api", "user/update.js", "PUT", "api/user/update"},
+		{"api", "user/profile.js", "GET", "api/user/profile"},
+		{"api/", "user/profile.js", "GET", "api/user/profile"},
+	}
+
+	for _, c := range cases {
+		method, path := scriptRouteFor(c.urlPrefix, c.rel)
+		if method != c.wantMethod || path != c.wantPath {
+			t.Errorf("scriptRouteFor(%q, %q) = (%q, %q), want (%q, %q)",
+				c.urlPrefix, c.rel, method, path, c.wantMethod, c.wantPath)
+		}
+	}
+}
+
+//TestScriptKeyDistinguishesSamePrefixlessRel 验证两个不同urlPrefix下同名的相对路径
+//(例如都存在user/login.js)不会产生同一个scriptCache key，否则后注册的目录会
+//悄悄覆盖/复用先注册目录的缓存与路由
+func TestScriptKeyDistinguishesSamePrefixlessRel(t *testing.T) {
+	rel := "user/login.js"
+	keyA := scriptKey("apiA", rel)
+	keyB := scriptKey("apiB", rel)
+	if keyA == keyB {
+		t.Fatalf("scriptKey(%q, %q) == scriptKey(%q, %q) = %q, want distinct keys", "apiA", rel, "apiB", rel, keyA)
+	}
+}
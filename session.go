@@ -0,0 +1,296 @@
+package dotweb
+
+import (
+	"devfeel/dotweb/framework/log"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	//DefaultSessionCookieName 默认的session cookie名称
+	DefaultSessionCookieName = "DOTWEB_SESSIONID"
+	//DefaultSessionTTL 默认session过期时间，与apigo服务插件保持一致
+	DefaultSessionTTL = 3600 * time.Second
+)
+
+type (
+	//SessionStore 定义session数据的存取方式，HttpServer.UseSession可传入不同实现
+	SessionStore interface {
+		Get(id string) (map[string]interface{}, error)
+		Save(id string, data map[string]interface{}, ttl time.Duration) error
+		Delete(id string) error
+	}
+
+	//sessionManager 挂载在HttpServer上，持有store及cookie相关配置
+	sessionManager struct {
+		store      SessionStore
+		cookieName string
+		ttl        time.Duration
+	}
+
+	//Session 绑定在单次请求上，延迟加载数据，仅在被修改后才在响应结束时写回store
+	Session struct {
+		id       string
+		manager  *sessionManager
+		data     map[string]interface{}
+		loaded   bool
+		modified bool
+		mutex    sync.Mutex
+	}
+)
+
+//UseSession 为HttpServer启用session支持，store为具体存储实现，cookieName为空时使用默认值，
+//ttl<=0时使用DefaultSessionTTL
+func (server *HttpServer) UseSession(store SessionStore, cookieName string, ttl time.Duration) {
+	if cookieName == "" {
+		cookieName = DefaultSessionCookieName
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	server.sessionManager = &sessionManager{
+		store:      store,
+		cookieName: cookieName,
+		ttl:        ttl,
+	}
+}
+
+//beginSession 在请求进入时读取/创建session cookie，并把Session实例挂到httpCtx上
+func (manager *sessionManager) beginSession(ctx *HttpContext) {
+	var id string
+	if cookie, err := ctx.Request.Cookie(manager.cookieName); err == nil && cookie.Value != "" {
+		id = cookie.Value
+	} else {
+		id = uuid.NewV4().String()
+		http.SetCookie(ctx.Response, &http.Cookie{
+			Name:     manager.cookieName,
+			Value:    id,
+			Path:     "/",
+			HttpOnly: true,
+			MaxAge:   int(manager.ttl / time.Second),
+		})
+	}
+
+	ctx.session = &Session{
+		id:      id,
+		manager: manager,
+	}
+}
+
+//endSession 在响应结束时，若session数据被修改过，才写回store，避免不必要的持久化开销；
+//写回失败时与wrapRouterHandle里的其它错误路径一样记录日志，而不是静默丢弃
+func (manager *sessionManager) endSession(ctx *HttpContext) {
+	session := ctx.session
+	if session == nil || !session.modified {
+		return
+	}
+	if err := manager.store.Save(session.id, session.data, manager.ttl); err != nil {
+		logger.Log("HttpServer:endSession save error["+session.id+"]:"+err.Error(), LogTarget_HttpServer, LogLevel_Error)
+	}
+}
+
+//Session 返回当前请求绑定的Session，未启用UseSession时返回nil
+func (ctx *HttpContext) Session() *Session {
+	return ctx.session
+}
+
+//Get 懒加载并返回session中key对应的值
+func (session *Session) Get(key string) (interface{}, error) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if err := session.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return session.data[key], nil
+}
+
+//Set 设置session中key对应的值，并标记session为已修改
+func (session *Session) Set(key string, value interface{}) error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if err := session.ensureLoaded(); err != nil {
+		return err
+	}
+	session.data[key] = value
+	session.modified = true
+	return nil
+}
+
+//Delete 清空当前session在store中的数据
+func (session *Session) Delete() error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if err := session.manager.store.Delete(session.id); err != nil {
+		return err
+	}
+	//同时清空本次请求内已加载/修改的数据，避免endSession在响应结束时又把旧数据写回store
+	session.data = make(map[string]interface{})
+	session.loaded = true
+	session.modified = false
+	return nil
+}
+
+func (session *Session) ensureLoaded() error {
+	if session.loaded {
+		return nil
+	}
+	data, err := session.manager.store.Get(session.id)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	session.data = data
+	session.loaded = true
+	return nil
+}
+
+//MemorySessionStore 基于内存的SessionStore实现，以分片map+RWMutex降低锁竞争，
+//并由后台goroutine周期性清理过期数据
+type MemorySessionStore struct {
+	shards    []*memoryShard
+	shardMask uint32
+}
+
+type memorySessionEntry struct {
+	data     map[string]interface{}
+	expireAt time.Time
+}
+
+type memoryShard struct {
+	mutex sync.RWMutex
+	items map[string]*memorySessionEntry
+}
+
+const memoryStoreShardCount = 32
+
+//NewMemorySessionStore 创建内存SessionStore，并启动周期sweepInterval的过期清理goroutine
+func NewMemorySessionStore(sweepInterval time.Duration) *MemorySessionStore {
+	store := &MemorySessionStore{
+		shards:    make([]*memoryShard, memoryStoreShardCount),
+		shardMask: memoryStoreShardCount - 1,
+	}
+	for i := range store.shards {
+		store.shards[i] = &memoryShard{items: make(map[string]*memorySessionEntry)}
+	}
+	if sweepInterval <= 0 {
+		sweepInterval = 60 * time.Second
+	}
+	go store.sweepLoop(sweepInterval)
+	return store
+}
+
+func (store *MemorySessionStore) shardFor(id string) *memoryShard {
+	var h uint32
+	for i := 0; i < len(id); i++ {
+		h = h*31 + uint32(id[i])
+	}
+	return store.shards[h&store.shardMask]
+}
+
+func (store *MemorySessionStore) Get(id string) (map[string]interface{}, error) {
+	shard := store.shardFor(id)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	entry, exists := shard.items[id]
+	if !exists || time.Now().After(entry.expireAt) {
+		return nil, nil
+	}
+	return entry.data, nil
+}
+
+func (store *MemorySessionStore) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	shard := store.shardFor(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.items[id] = &memorySessionEntry{data: data, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (store *MemorySessionStore) Delete(id string) error {
+	shard := store.shardFor(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	delete(shard.items, id)
+	return nil
+}
+
+func (store *MemorySessionStore) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, shard := range store.shards {
+			shard.mutex.Lock()
+			for id, entry := range shard.items {
+				if now.After(entry.expireAt) {
+					delete(shard.items, id)
+				}
+			}
+			shard.mutex.Unlock()
+		}
+	}
+}
+
+//RedisSessionStore 基于Redis的SessionStore实现，key以SESS_前缀命名，
+//使数据可以在进程重启后继续存活
+type RedisSessionStore struct {
+	pool *redis.Pool
+}
+
+//NewRedisSessionStore 基于已建立好的redis.Pool创建RedisSessionStore
+func NewRedisSessionStore(pool *redis.Pool) *RedisSessionStore {
+	return &RedisSessionStore{pool: pool}
+}
+
+func redisSessionKey(id string) string {
+	return "SESS_" + id
+}
+
+//Get 读取并按JSON反序列化整个session，与Save写入时的编码一致；数字会还原为float64，
+//嵌套结构会还原为map[string]interface{}或[]interface{}，而不是写入时的原始Go类型
+func (store *RedisSessionStore) Get(id string) (map[string]interface{}, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	raw, err := redis.Bytes(conn.Do("GET", redisSessionKey(id)))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//Save 把整个session编码成一个JSON值后整体写入，而不是按字段拆成HMSET，
+//这样数字、布尔、嵌套结构这类非字符串值在Get时仍是对应的JSON值（数字为float64），而不是被转成字符串
+func (store *RedisSessionStore) Save(id string, data map[string]interface{}, ttl time.Duration) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("SET", redisSessionKey(id), raw, "EX", int(ttl/time.Second))
+	return err
+}
+
+func (store *RedisSessionStore) Delete(id string) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", redisSessionKey(id))
+	return err
+}
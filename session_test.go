@@ -0,0 +1,56 @@
+package dotweb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStoreSaveGet(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+
+	data := map[string]interface{}{"uid": "42"}
+	if err := store.Save("sid1", data, time.Minute); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	got, err := store.Get("sid1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got["uid"] != "42" {
+		t.Fatalf("Get = %v, want uid=42", got)
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+
+	if err := store.Save("sid2", map[string]interface{}{"k": "v"}, 10*time.Millisecond); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	got, err := store.Get("sid2")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("Get after expiry = %v, want nil", got)
+	}
+}
+
+func TestMemorySessionStoreDelete(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+
+	store.Save("sid3", map[string]interface{}{"k": "v"}, time.Minute)
+	if err := store.Delete("sid3"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	got, err := store.Get("sid3")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("Get after Delete = %v, want nil", got)
+	}
+}
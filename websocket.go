@@ -0,0 +1,31 @@
+package dotweb
+
+import (
+	"golang.org/x/net/websocket"
+)
+
+//WebSocket 对golang.org/x/net/websocket.Conn的简单封装，记录当前连接已经收发的消息数，
+//供Metrics.ObserveWebSocket在连接关闭时上报。用户handle应通过Send/Receive收发消息，
+//而不是绕开它直接操作Conn，否则MessageCount无法被正确统计
+type WebSocket struct {
+	Conn         *websocket.Conn
+	MessageCount int64
+}
+
+//Send 通过websocket.Message向客户端发送一条消息，发送成功后计入MessageCount
+func (ws *WebSocket) Send(data interface{}) error {
+	err := websocket.Message.Send(ws.Conn, data)
+	if err == nil {
+		ws.MessageCount++
+	}
+	return err
+}
+
+//Receive 通过websocket.Message从客户端接收一条消息，接收成功后计入MessageCount
+func (ws *WebSocket) Receive(data interface{}) error {
+	err := websocket.Message.Receive(ws.Conn, data)
+	if err == nil {
+		ws.MessageCount++
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package dotweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketMessageCountIncrementsOnSendAndReceive(t *testing.T) {
+	var serverWS *WebSocket
+	//服务端在Send返回之后才累加MessageCount，客户端收到回复时计数可能尚未更新，需等handler返回再断言
+	done := make(chan struct{})
+
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		defer close(done)
+		serverWS = &WebSocket{Conn: conn}
+
+		var msg string
+		if err := serverWS.Receive(&msg); err != nil {
+			t.Errorf("Receive failed: %v", err)
+			return
+		}
+		if err := serverWS.Send("pong"); err != nil {
+			t.Errorf("Send failed: %v", err)
+			return
+		}
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	origin := server.URL
+	client, err := websocket.Dial(wsURL, "", origin)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := websocket.Message.Send(client, "ping"); err != nil {
+		t.Fatalf("client send failed: %v", err)
+	}
+	var reply string
+	if err := websocket.Message.Receive(client, &reply); err != nil {
+		t.Fatalf("client receive failed: %v", err)
+	}
+	if reply != "pong" {
+		t.Fatalf("reply = %q, want pong", reply)
+	}
+
+	<-done
+	if serverWS == nil {
+		t.Fatal("server handler never ran")
+	}
+	if serverWS.MessageCount != 2 {
+		t.Fatalf("MessageCount = %d, want 2 (one Receive + one Send)", serverWS.MessageCount)
+	}
+}